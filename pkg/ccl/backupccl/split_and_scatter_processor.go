@@ -11,12 +11,18 @@ package backupccl
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/ccl/storageccl"
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/gossip"
+	"github.com/cockroachdb/cockroach/pkg/jobs"
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/execinfra"
 	"github.com/cockroachdb/cockroach/pkg/sql/execinfrapb"
@@ -26,15 +32,556 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/types"
 	"github.com/cockroachdb/cockroach/pkg/util/ctxgroup"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/cockroach/pkg/util/retry"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing"
 	"github.com/cockroachdb/errors"
 )
 
+// scatterMaxRetries bounds the number of times a single AdminScatter request
+// is retried before the scatterer gives up and falls back to a round-robin
+// destination.
+var scatterMaxRetries = settings.RegisterIntSetting(
+	"bulkio.restore.scatter.max_retries",
+	"the number of times to retry a scatter request before falling back to "+
+		"a round-robin node assignment",
+	5,
+)
+
+// scatterInitialBackoff is the initial backoff duration used between scatter
+// retries.
+var scatterInitialBackoff = settings.RegisterDurationSetting(
+	"bulkio.restore.scatter.initial_backoff",
+	"the initial backoff duration between scatter retries",
+	100*time.Millisecond,
+)
+
+// scatterMaxBackoff caps the backoff duration used between scatter retries.
+var scatterMaxBackoff = settings.RegisterDurationSetting(
+	"bulkio.restore.scatter.max_backoff",
+	"the maximum backoff duration between scatter retries",
+	15*time.Second,
+)
+
+// scatterStrategyRandom is the legacy behavior: scatter with
+// RandomizeLeases and let the allocator decide where everything ends up.
+// scatterStrategyZoneAware additionally steers the resulting lease towards a
+// replica matching the destination span's zone configuration.
+// scatterStrategyNoop skips both the split and the scatter altogether.
+const (
+	scatterStrategyRandom    = "random"
+	scatterStrategyZoneAware = "zone_aware"
+	scatterStrategyNoop      = "noop"
+)
+
+// scatterStrategySetting selects which splitAndScatterer implementation
+// backs a RESTORE's split/scatter processor.
+var scatterStrategySetting = settings.RegisterEnumSetting(
+	"bulkio.restore.scatter.strategy",
+	"strategy used to distribute restored spans across the cluster: "+
+		"'random' scatters with randomized leases, 'zone_aware' additionally "+
+		"relocates the lease towards a replica matching the destination's zone "+
+		"configuration, and 'noop' skips the split and the scatter entirely",
+	scatterStrategyRandom,
+	map[int64]string{
+		0: scatterStrategyRandom,
+		1: scatterStrategyZoneAware,
+		2: scatterStrategyNoop,
+	},
+)
+
+// resolveScatterStrategy returns the split/scatter strategy to use for a
+// RESTORE: an explicit per-job override recorded on the spec takes
+// precedence over the bulkio.restore.scatter.strategy cluster setting, so a
+// RESTORE that started under one setting value keeps using the strategy it
+// started with even if an operator changes the setting mid-job. If the spec
+// doesn't carry an override, the cluster setting is used as before.
+//
+// NOTE: spec.ScatterStrategy is a new field on execinfrapb.SplitAndScatterSpec
+// that is not present in this snapshot of the tree; it needs to land alongside
+// a companion proto/generated-code change before this builds.
+func resolveScatterStrategy(spec execinfrapb.SplitAndScatterSpec, sv *settings.Values) string {
+	if spec.ScatterStrategy != "" {
+		return spec.ScatterStrategy
+	}
+	return scatterStrategySetting.Get(sv)
+}
+
+// scatterWorkersMin/Max/Initial bound the adaptive split/scatter worker
+// pool. The pool is seeded from the live node count (clamped to this range)
+// and then adjusted with AIMD-style feedback as scatters succeed or fail.
+var scatterWorkersMin = settings.RegisterIntSetting(
+	"bulkio.restore.scatter.min_workers",
+	"the minimum number of concurrent split/scatter workers during a restore",
+	1,
+)
+
+var scatterWorkersMax = settings.RegisterIntSetting(
+	"bulkio.restore.scatter.max_workers",
+	"the maximum number of concurrent split/scatter workers during a restore",
+	64,
+)
+
+var scatterWorkersInitial = settings.RegisterIntSetting(
+	"bulkio.restore.scatter.initial_workers",
+	"the initial number of concurrent split/scatter workers during a restore, "+
+		"before the adaptive pool starts reacting to observed latency/errors; "+
+		"if 0, the live node count is used",
+	0,
+)
+
+// scatterSlowThreshold is the per-scatter latency above which the adaptive
+// worker pool treats the call as a sign of overload and backs off.
+var scatterSlowThreshold = settings.RegisterDurationSetting(
+	"bulkio.restore.scatter.slow_threshold",
+	"a split/scatter call slower than this is treated as a sign of overload "+
+		"and causes the adaptive worker pool to back off",
+	5*time.Second,
+)
+
+// scatterCheckpointEntries and scatterCheckpointInterval govern how often
+// split/scatter progress is persisted to the RESTORE job's progress record,
+// so that a paused/resumed job (or a processor that dies and is restarted
+// elsewhere) doesn't re-split spans that were already handled.
+var scatterCheckpointEntries = settings.RegisterIntSetting(
+	"bulkio.restore.scatter.checkpoint_entries",
+	"the number of split/scatter entries between progress checkpoints",
+	1000,
+)
+
+var scatterCheckpointInterval = settings.RegisterDurationSetting(
+	"bulkio.restore.scatter.checkpoint_interval",
+	"the amount of time between split/scatter progress checkpoints",
+	30*time.Second,
+)
+
+// scatterMetrics tracks how well the split/scatter step of a RESTORE is
+// managing to spread work across the cluster. These are surfaced so
+// operators can tell whether a slow RESTORE is actually distributing work or
+// quietly funneling everything into the default stream.
+type scatterMetrics struct {
+	Attempts        *metric.Counter
+	Failures        *metric.Counter
+	Fallbacks       *metric.Counter
+	DestinationZero *metric.Counter
+	Concurrency     *metric.Gauge
+}
+
+var metaScatterAttempts = metric.Metadata{
+	Name:        "restore.scatter.attempts",
+	Help:        "Number of AdminScatter requests sent during RESTORE, including retries",
+	Measurement: "Requests",
+	Unit:        metric.Unit_COUNT,
+}
+var metaScatterFailures = metric.Metadata{
+	Name:        "restore.scatter.failures",
+	Help:        "Number of AdminScatter requests that returned an error during RESTORE",
+	Measurement: "Requests",
+	Unit:        metric.Unit_COUNT,
+}
+var metaScatterFallbacks = metric.Metadata{
+	Name:        "restore.scatter.fallbacks",
+	Help:        "Number of times RESTORE fell back to round-robin node assignment after scatter retries were exhausted",
+	Measurement: "Events",
+	Unit:        metric.Unit_COUNT,
+}
+var metaScatterDestinationZero = metric.Metadata{
+	Name:        "restore.scatter.destination_zero",
+	Help:        "Number of scattered spans for which a destination node could not be determined",
+	Measurement: "Events",
+	Unit:        metric.Unit_COUNT,
+}
+var metaScatterConcurrency = metric.Metadata{
+	Name:        "restore.scatter.concurrency",
+	Help:        "Current number of concurrent split/scatter workers for RESTORE",
+	Measurement: "Workers",
+	Unit:        metric.Unit_COUNT,
+}
+
+func makeScatterMetrics() scatterMetrics {
+	return scatterMetrics{
+		Attempts:        metric.NewCounter(metaScatterAttempts),
+		Failures:        metric.NewCounter(metaScatterFailures),
+		Fallbacks:       metric.NewCounter(metaScatterFallbacks),
+		DestinationZero: metric.NewCounter(metaScatterDestinationZero),
+		Concurrency:     metric.NewGauge(metaScatterConcurrency),
+	}
+}
+
+// MetricStruct implements the metric.Struct interface, marking scatterMetrics
+// as a bag of metrics that can be registered with a metric.Registry via
+// AddMetricStruct.
+func (scatterMetrics) MetricStruct() {}
+
+var scatterMetricsMu struct {
+	syncutil.Mutex
+	// byRegistry caches one scatterMetrics per *jobs.Registry, i.e. per node.
+	// A process that hosts more than one logical node (e.g. a TestCluster, or
+	// serial TestServers sharing a binary) has one jobs.Registry per node, so
+	// keying off it (rather than a single process-wide sync.Once) ensures
+	// every node gets its own counters registered with its own metrics
+	// registry, instead of only the first node to call getScatterMetrics.
+	byRegistry map[*jobs.Registry]scatterMetrics
+}
+
+// getScatterMetrics returns the per-node split/scatter metrics, creating and
+// registering them with flowCtx's node's metrics registry the first time a
+// split/scatter processor is created on that node. Every RESTORE on a given
+// node shares that node's counters; unlike a fresh makeScatterMetrics() per
+// processor, these survive the processor that created them and are actually
+// reachable at /_status/vars instead of being discarded when the RESTORE
+// finishes.
+func getScatterMetrics(flowCtx *execinfra.FlowCtx) scatterMetrics {
+	registry := flowCtx.Cfg.JobRegistry
+
+	scatterMetricsMu.Lock()
+	defer scatterMetricsMu.Unlock()
+	if m, ok := scatterMetricsMu.byRegistry[registry]; ok {
+		return m
+	}
+	m := makeScatterMetrics()
+	if reg := registry.MetricsStruct(); reg != nil {
+		reg.AddMetricStruct(m)
+	}
+	if scatterMetricsMu.byRegistry == nil {
+		scatterMetricsMu.byRegistry = make(map[*jobs.Registry]scatterMetrics)
+	}
+	scatterMetricsMu.byRegistry[registry] = m
+	return m
+}
+
+// adaptiveWorkerPool hands out a dynamically sized number of concurrency
+// permits. The limit starts at initial (clamped to [min, max]) and is then
+// adjusted AIMD-style: a slow or failed scatter halves it, and a run of
+// successes grows it by one, up to max.
+type adaptiveWorkerPool struct {
+	tokens chan struct{}
+	min    int32
+	max    int32
+	cur    int32 // atomic
+
+	// toDrop counts permits that should be discarded (rather than returned to
+	// tokens) the next time they're released, to bring the number of permits
+	// in circulation down to cur after a decrease that couldn't be satisfied
+	// by draining idle tokens alone.
+	toDrop int32 // atomic
+
+	successStreak int32 // atomic
+	metrics       scatterMetrics
+}
+
+// scatterSuccessStreakForIncrease is the number of consecutive fast,
+// successful scatters required before the pool grows its concurrency limit
+// by one. This is deliberately slower than the backoff reaction so a single
+// burst of slow calls can't be immediately undone.
+const scatterSuccessStreakForIncrease = 10
+
+func newAdaptiveWorkerPool(min, max, initial int32, metrics scatterMetrics) *adaptiveWorkerPool {
+	// A floor of 0 would let decrease() CAS the pool's concurrency down to 0
+	// permits, at which point acquire() blocks forever (nothing is left to
+	// hand out, and increaseOnStreak only ever runs from inside a release()
+	// that itself required a prior successful acquire()). Enforce a floor of
+	// 1 regardless of what bulkio.restore.scatter.min_workers reports.
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	if initial < min {
+		initial = min
+	}
+	if initial > max {
+		initial = max
+	}
+	p := &adaptiveWorkerPool{
+		tokens:  make(chan struct{}, max),
+		min:     min,
+		max:     max,
+		cur:     initial,
+		metrics: metrics,
+	}
+	for i := int32(0); i < initial; i++ {
+		p.tokens <- struct{}{}
+	}
+	metrics.Concurrency.Update(int64(initial))
+	return p
+}
+
+// acquire blocks until a concurrency permit is available or ctx is done.
+func (p *adaptiveWorkerPool) acquire(ctx context.Context) error {
+	select {
+	case <-p.tokens:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release returns a permit, recording whether the call it guarded was slow
+// or erroneous so the pool can adjust its limit.
+func (p *adaptiveWorkerPool) release(degraded bool) {
+	if degraded {
+		p.decrease()
+	} else {
+		p.increaseOnStreak()
+	}
+
+	// If a prior decrease couldn't be satisfied by draining idle tokens
+	// alone, discard this permit instead of returning it, so the number of
+	// permits in circulation converges on cur.
+	for {
+		d := atomic.LoadInt32(&p.toDrop)
+		if d <= 0 {
+			break
+		}
+		if atomic.CompareAndSwapInt32(&p.toDrop, d, d-1) {
+			return
+		}
+	}
+	select {
+	case p.tokens <- struct{}{}:
+	default:
+		// Shouldn't happen (tokens is sized to max), but don't block release.
+	}
+}
+
+func (p *adaptiveWorkerPool) decrease() {
+	atomic.StoreInt32(&p.successStreak, 0)
+	// Re-derive the floor here (rather than trusting p.min outright) so this
+	// stays safe even if that invariant is ever violated upstream.
+	min := p.min
+	if min < 1 {
+		min = 1
+	}
+	for {
+		cur := atomic.LoadInt32(&p.cur)
+		next := cur / 2
+		if next < min {
+			next = min
+		}
+		if next == cur {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&p.cur, cur, next) {
+			delta := cur - next
+			drained := p.drainIdle(delta)
+			atomic.AddInt32(&p.toDrop, delta-drained)
+			p.metrics.Concurrency.Update(int64(next))
+			return
+		}
+	}
+}
+
+func (p *adaptiveWorkerPool) increaseOnStreak() {
+	if atomic.AddInt32(&p.successStreak, 1) < scatterSuccessStreakForIncrease {
+		return
+	}
+	atomic.StoreInt32(&p.successStreak, 0)
+	for {
+		cur := atomic.LoadInt32(&p.cur)
+		if cur >= p.max {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&p.cur, cur, cur+1) {
+			p.tokens <- struct{}{}
+			p.metrics.Concurrency.Update(int64(cur + 1))
+			return
+		}
+	}
+}
+
+// drainIdle removes up to n idle tokens from the pool without blocking,
+// returning the number actually removed.
+func (p *adaptiveWorkerPool) drainIdle(n int32) int32 {
+	var drained int32
+	for ; drained < n; drained++ {
+		select {
+		case <-p.tokens:
+		default:
+			return drained
+		}
+	}
+	return drained
+}
+
+// checkpointStore persists and reloads the set of split/scatter spans a
+// RESTORE has already completed. jobRegistryCheckpointStore is the
+// production implementation, backed by the RESTORE job's own progress
+// record; tests substitute a fake in order to exercise checkpoint/resume
+// without a real jobs.Registry.
+//
+// NOTE: jobRegistryCheckpointStore below depends on two fields that are not
+// present in this snapshot of the tree: spec.JobID on
+// execinfrapb.SplitAndScatterSpec, and Checkpoint on jobspb.RestoreProgress.
+// Both need to land alongside a companion proto/generated-code change before
+// this compiles. The checkpoint/resume behavior below is exercised by
+// TestScatterCheckpointResumeIsIdempotent, which lives in this package and so
+// builds only once those fields are present as well.
+type checkpointStore interface {
+	// Load returns the set of span keys already completed by a prior attempt,
+	// or nil if there's no checkpoint yet.
+	Load(ctx context.Context) (map[string]struct{}, error)
+	// Save persists the given set of completed span keys.
+	Save(ctx context.Context, completed []string) error
+}
+
+// jobRegistryCheckpointStore implements checkpointStore on top of a RESTORE
+// job's jobspb.RestoreProgress, so that a paused/resumed job (or a processor
+// that dies and is restarted elsewhere) doesn't re-split spans that were
+// already handled.
+type jobRegistryCheckpointStore struct {
+	registry *jobs.Registry
+	jobID    jobspb.JobID
+}
+
+// Load implements checkpointStore.
+func (s jobRegistryCheckpointStore) Load(ctx context.Context) (map[string]struct{}, error) {
+	if s.registry == nil {
+		return nil, nil
+	}
+	job, err := s.registry.LoadJob(ctx, s.jobID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading restore job %d to resume scatter progress", s.jobID)
+	}
+	restoreProgress := job.Progress().GetRestore()
+	if restoreProgress == nil {
+		return nil, nil
+	}
+	completed := make(map[string]struct{}, len(restoreProgress.Checkpoint))
+	for _, key := range restoreProgress.Checkpoint {
+		completed[key] = struct{}{}
+	}
+	return completed, nil
+}
+
+// Save implements checkpointStore.
+func (s jobRegistryCheckpointStore) Save(ctx context.Context, completed []string) error {
+	if s.registry == nil {
+		return nil
+	}
+	job, err := s.registry.LoadJob(ctx, s.jobID)
+	if err != nil {
+		return errors.Wrapf(err, "loading restore job %d to checkpoint scatter progress", s.jobID)
+	}
+	if err := job.Update(ctx, nil /* txn */, func(
+		_ *kv.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		progress := md.Progress
+		restoreProgress := progress.GetRestore()
+		if restoreProgress == nil {
+			return nil
+		}
+		restoreProgress.Checkpoint = completed
+		ju.UpdateProgress(progress)
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "persisting scatter checkpoint for restore job %d", s.jobID)
+	}
+	return nil
+}
+
+// scatterCheckpoint tracks which RestoreSpanEntry spans this processor has
+// already split and scattered, and periodically persists that set back to
+// the RESTORE job's progress record via its store. On resume,
+// runSplitAndScatter uses loadScatterCheckpoint to read it back and
+// filter spec.Chunks so already-scattered entries (and their AdminSplit
+// calls) aren't redone.
+type scatterCheckpoint struct {
+	store checkpointStore
+
+	mu struct {
+		syncutil.Mutex
+		completed map[string]struct{}
+		dirty     int
+	}
+	lastFlush time.Time
+}
+
+func newScatterCheckpoint(store checkpointStore, initial map[string]struct{}) *scatterCheckpoint {
+	c := &scatterCheckpoint{store: store, lastFlush: timeutil.Now()}
+	if initial == nil {
+		initial = make(map[string]struct{})
+	}
+	c.mu.completed = initial
+	return c
+}
+
+// isDone returns whether key was recorded as completed, either by a prior
+// attempt at this RESTORE (i.e. a checkpoint loaded at construction time) or
+// by markDone in this attempt.
+func (c *scatterCheckpoint) isDone(key roachpb.Key) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.mu.completed[string(key)]
+	return ok
+}
+
+// markDone records that key has been split and scattered in this attempt.
+func (c *scatterCheckpoint) markDone(key roachpb.Key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mu.completed[string(key)] = struct{}{}
+	c.mu.dirty++
+}
+
+// maybeFlush persists the checkpoint if enough entries have completed since
+// the last flush, or enough time has passed, per the
+// bulkio.restore.scatter.checkpoint_* cluster settings.
+func (c *scatterCheckpoint) maybeFlush(ctx context.Context, sv *settings.Values) error {
+	c.mu.Lock()
+	due := c.mu.dirty >= int(scatterCheckpointEntries.Get(sv)) ||
+		timeutil.Since(c.lastFlush) >= scatterCheckpointInterval.Get(sv)
+	c.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return c.flush(ctx)
+}
+
+// flush unconditionally persists the checkpoint via its store.
+func (c *scatterCheckpoint) flush(ctx context.Context) error {
+	if c.store == nil {
+		return nil
+	}
+	c.mu.Lock()
+	completed := make([]string, 0, len(c.mu.completed))
+	for key := range c.mu.completed {
+		completed = append(completed, key)
+	}
+	c.mu.Unlock()
+
+	if err := c.store.Save(ctx, completed); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.mu.dirty = 0
+	c.lastFlush = timeutil.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// loadScatterCheckpoint reads back the set of already-completed span keys
+// from store, for use when a split/scatter processor is resumed after a
+// pause or a node failure.
+func loadScatterCheckpoint(ctx context.Context, store checkpointStore) (map[string]struct{}, error) {
+	if store == nil {
+		return nil, nil
+	}
+	return store.Load(ctx)
+}
+
 type splitAndScatterer interface {
 	// splitAndScatterSpan issues a split request at a given key and then scatters
 	// the range around the cluster. It returns the node ID of the leaseholder of
-	// the span after the scatter.
-	splitAndScatterKey(ctx context.Context, codec keys.SQLCodec, db *kv.DB, kr *storageccl.KeyRewriter, key roachpb.Key, randomizeLeases bool) (roachpb.NodeID, error)
+	// the span after the scatter. chunkIdx identifies which chunk of the plan
+	// key belongs to, and is only used to annotate the tracing span for this
+	// operation.
+	splitAndScatterKey(ctx context.Context, codec keys.SQLCodec, db *kv.DB, kr *storageccl.KeyRewriter, key roachpb.Key, chunkIdx int, randomizeLeases bool) (roachpb.NodeID, error)
 }
 
 type noopSplitAndScatterer struct{}
@@ -44,7 +591,7 @@ type noopSplitAndScatterer struct{}
 // router has a `DefaultStream` specified in case the range generated by this
 // node ID doesn't match any of the result router's spans.
 func (n noopSplitAndScatterer) splitAndScatterKey(
-	_ context.Context, _ keys.SQLCodec, _ *kv.DB, _ *storageccl.KeyRewriter, _ roachpb.Key, _ bool,
+	_ context.Context, _ keys.SQLCodec, _ *kv.DB, _ *storageccl.KeyRewriter, _ roachpb.Key, _ int, _ bool,
 ) (roachpb.NodeID, error) {
 	return 0, nil
 }
@@ -52,34 +599,95 @@ func (n noopSplitAndScatterer) splitAndScatterKey(
 // dbSplitAndScatter is the production implementation of this processor's
 // scatterer. It actually issues the split and scatter requests for KV. This is
 // mocked out in some tests.
-type dbSplitAndScatterer struct{}
+type dbSplitAndScatterer struct {
+	settings *settings.Values
+	metrics  scatterMetrics
+
+	// nextFallbackNode is used to round-robin a destination assignment across
+	// fallbackNodes once scatter retries have been exhausted. It is advanced
+	// with atomic.AddUint32 since splitAndScatterKey is called concurrently by
+	// multiple workers.
+	nextFallbackNode uint32
+	fallbackNodes    func() []roachpb.NodeID
+}
+
+// makeDBSplitAndScatterer constructs a dbSplitAndScatterer. fallbackNodes, if
+// non-nil, is consulted for a round-robin destination once scatter retries
+// are exhausted; it is expected to return the live nodes in the cluster (e.g.
+// sourced from gossip or the dialer's known peers).
+func makeDBSplitAndScatterer(
+	st *settings.Values, metrics scatterMetrics, fallbackNodes func() []roachpb.NodeID,
+) *dbSplitAndScatterer {
+	return &dbSplitAndScatterer{
+		settings:      st,
+		metrics:       metrics,
+		fallbackNodes: fallbackNodes,
+	}
+}
 
 // splitAndScatterKey implements the splitAndScatterer interface.
 // It splits and scatters a span specified by a given key, and returns the node
 // to which the span was scattered. If the destination node could not be
 // determined, node ID of 0 is returned.
-func (s dbSplitAndScatterer) splitAndScatterKey(
+//
+// The scatter itself is retried with exponential backoff (governed by the
+// bulkio.restore.scatter.* cluster settings) since AdminScatter is prone to
+// transient failures under load. If every retry fails, splitAndScatterKey
+// falls back to a round-robin assignment over the live nodes supplied by
+// fallbackNodes rather than letting the entry default to node 0, which would
+// otherwise funnel it into the range router's default stream and create a
+// hot spot.
+//
+// The whole operation is wrapped in a child tracing span tagged with the
+// rewritten key, chunk index, destination node, retry count and
+// randomizeLeases, so that `SHOW TRACE FOR JOB` can surface per-range
+// scatter timings for a RESTORE.
+func (s *dbSplitAndScatterer) splitAndScatterKey(
 	ctx context.Context,
 	codec keys.SQLCodec,
 	db *kv.DB,
 	kr *storageccl.KeyRewriter,
 	key roachpb.Key,
+	chunkIdx int,
 	randomizeLeases bool,
 ) (roachpb.NodeID, error) {
+	dest, _, err := s.splitAndScatterKeyWithResponse(ctx, codec, db, kr, key, chunkIdx, randomizeLeases)
+	return dest, err
+}
+
+// splitAndScatterKeyWithResponse does the same work as splitAndScatterKey,
+// additionally returning the AdminScatterResponse from a successful scatter
+// (nil if the scatter fell back to round-robin) so that callers like
+// zoneAwareSplitAndScatterer can inspect the resulting range descriptor
+// without issuing a second RPC.
+func (s *dbSplitAndScatterer) splitAndScatterKeyWithResponse(
+	ctx context.Context,
+	codec keys.SQLCodec,
+	db *kv.DB,
+	kr *storageccl.KeyRewriter,
+	key roachpb.Key,
+	chunkIdx int,
+	randomizeLeases bool,
+) (roachpb.NodeID, *roachpb.AdminScatterResponse, error) {
+	sp, ctx := tracing.ChildSpan(ctx, "split-and-scatter-key")
+	defer sp.Finish()
+	sp.SetTag("chunk", chunkIdx)
+	sp.SetTag("randomizeLeases", randomizeLeases)
+
 	expirationTime := db.Clock().Now().Add(time.Hour.Nanoseconds(), 0)
 	newSpanKey, err := rewriteBackupSpanKey(codec, kr, key)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
+	sp.SetTag("key", newSpanKey.String())
 
 	// TODO(pbardea): Really, this should be splitting the Key of the _next_
 	// entry.
 	log.VEventf(ctx, 1, "presplitting new key %+v", newSpanKey)
 	if err := db.AdminSplit(ctx, newSpanKey, expirationTime); err != nil {
-		return 0, errors.Wrapf(err, "splitting key %s", newSpanKey)
+		return 0, nil, errors.Wrapf(err, "splitting key %s", newSpanKey)
 	}
 
-	log.VEventf(ctx, 1, "scattering new key %+v", newSpanKey)
 	req := &roachpb.AdminScatterRequest{
 		RequestHeader: roachpb.RequestHeaderFromSpan(roachpb.Span{
 			Key:    newSpanKey,
@@ -95,24 +703,79 @@ func (s dbSplitAndScatterer) splitAndScatterKey(
 		RandomizeLeases: randomizeLeases,
 	}
 
-	res, pErr := kv.SendWrapped(ctx, db.NonTransactionalSender(), req)
-	if pErr != nil {
+	retryOpts := retry.Options{
+		InitialBackoff: scatterInitialBackoff.Get(s.settings),
+		MaxBackoff:     scatterMaxBackoff.Get(s.settings),
+		Multiplier:     2,
+		MaxRetries:     int(scatterMaxRetries.Get(s.settings)),
+	}
+
+	var res *roachpb.AdminScatterResponse
+	var lastErr error
+	var attempt int
+	for r := retry.StartWithCtx(ctx, retryOpts); r.Next(); {
+		attempt = r.CurrentAttempt()
+		log.VEventf(ctx, 1, "scattering new key %+v (attempt %d)", newSpanKey, attempt+1)
+		s.metrics.Attempts.Inc(1)
+		rawRes, pErr := kv.SendWrapped(ctx, db.NonTransactionalSender(), req)
+		if pErr == nil {
+			res = rawRes.(*roachpb.AdminScatterResponse)
+			lastErr = nil
+			break
+		}
+		lastErr = pErr.GoError()
+		s.metrics.Failures.Inc(1)
+		sp.Recordf("scatter attempt %d failed: %s", attempt+1, lastErr)
+		log.Warningf(ctx, "failed to scatter span [%s,%s) (attempt %d): %+v",
+			newSpanKey, newSpanKey.Next(), attempt+1, lastErr)
+	}
+	sp.SetTag("retries", attempt)
+
+	if lastErr != nil {
 		// TODO(pbardea): Unfortunately, Scatter is still too unreliable to
 		// fail the RESTORE when Scatter fails. I'm uncomfortable that
 		// this could break entirely and not start failing the tests,
 		// but on the bright side, it doesn't affect correctness, only
 		// throughput.
-		log.Errorf(ctx, "failed to scatter span [%s,%s): %+v",
-			newSpanKey, newSpanKey.Next(), pErr.GoError())
-		return 0, nil
+		sp.Recordf("scatter failed after %d attempts, falling back to round-robin: %s", attempt+1, lastErr)
+		log.Errorf(ctx, "failed to scatter span [%s,%s) after retries, falling back to round-robin: %+v",
+			newSpanKey, newSpanKey.Next(), lastErr)
+		s.metrics.Fallbacks.Inc(1)
+		if dest, ok := s.roundRobinFallback(); ok {
+			sp.SetTag("destination", dest)
+			return dest, nil, nil
+		}
+		s.metrics.DestinationZero.Inc(1)
+		return 0, nil, nil
 	}
 
-	return s.findDestination(res.(*roachpb.AdminScatterResponse)), nil
+	dest := s.findDestination(res)
+	sp.SetTag("destination", dest)
+	if dest == 0 {
+		s.metrics.DestinationZero.Inc(1)
+	}
+	return dest, res, nil
+}
+
+// roundRobinFallback returns the next node in the round-robin rotation over
+// s.fallbackNodes(), if any live nodes are known. This keeps entries whose
+// scatter failed from all defaulting to node 0 (and thus to the range
+// router's default stream) during a restore.
+func (s *dbSplitAndScatterer) roundRobinFallback() (roachpb.NodeID, bool) {
+	if s.fallbackNodes == nil {
+		return 0, false
+	}
+	nodes := s.fallbackNodes()
+	if len(nodes) == 0 {
+		return 0, false
+	}
+	idx := atomic.AddUint32(&s.nextFallbackNode, 1) - 1
+	return nodes[int(idx)%len(nodes)], true
 }
 
 // findDestination returns the node ID of the node of the destination of the
 // AdminScatter request. If the destination cannot be found, 0 is returned.
-func (s dbSplitAndScatterer) findDestination(res *roachpb.AdminScatterResponse) roachpb.NodeID {
+func (s *dbSplitAndScatterer) findDestination(res *roachpb.AdminScatterResponse) roachpb.NodeID {
 	// A request from a 20.1 node will not have a RangeInfos with a lease.
 	// For this mixed-version state, we'll report the destination as node 0
 	// and suffer a bit of inefficiency.
@@ -127,6 +790,159 @@ func (s dbSplitAndScatterer) findDestination(res *roachpb.AdminScatterResponse)
 	return roachpb.NodeID(0)
 }
 
+// zoneAwareSplitAndScatterer extends dbSplitAndScatterer's split-and-scatter
+// with a pass that steers the resulting lease towards a replica that
+// satisfies the destination span's zone configuration. This is meant to let
+// a multi-region RESTORE land data near its eventual home, instead of
+// relying on the allocator to rebalance it there afterwards.
+type zoneAwareSplitAndScatterer struct {
+	dbSplitAndScatterer
+
+	// zoneConfigForKey resolves the zone configuration that applies to a
+	// (rewritten) key, typically backed by the gateway's system config.
+	zoneConfigForKey func(roachpb.Key) (*zonepb.ZoneConfig, error)
+
+	// nodeLocalities resolves the known live nodes' localities (typically
+	// sourced from gossip), for matching against a zone config's lease
+	// preferences when picking a relocation target.
+	nodeLocalities func() map[roachpb.NodeID]roachpb.Locality
+}
+
+// makeZoneAwareSplitAndScatterer constructs a zoneAwareSplitAndScatterer.
+func makeZoneAwareSplitAndScatterer(
+	base *dbSplitAndScatterer,
+	zoneConfigForKey func(roachpb.Key) (*zonepb.ZoneConfig, error),
+	nodeLocalities func() map[roachpb.NodeID]roachpb.Locality,
+) *zoneAwareSplitAndScatterer {
+	return &zoneAwareSplitAndScatterer{
+		dbSplitAndScatterer: *base,
+		zoneConfigForKey:    zoneConfigForKey,
+		nodeLocalities:      nodeLocalities,
+	}
+}
+
+// splitAndScatterKey implements the splitAndScatterer interface. It defers
+// the actual split and scatter to dbSplitAndScatterer, then, if the
+// destination span has lease preferences configured, issues an
+// AdminRelocateRange/AdminTransferLease pair to move the lease onto a
+// replica that satisfies them.
+func (z *zoneAwareSplitAndScatterer) splitAndScatterKey(
+	ctx context.Context,
+	codec keys.SQLCodec,
+	db *kv.DB,
+	kr *storageccl.KeyRewriter,
+	key roachpb.Key,
+	chunkIdx int,
+	randomizeLeases bool,
+) (roachpb.NodeID, error) {
+	dest, res, err := z.dbSplitAndScatterer.splitAndScatterKeyWithResponse(ctx, codec, db, kr, key, chunkIdx, randomizeLeases)
+	if err != nil {
+		return dest, err
+	}
+	if res == nil || len(res.RangeInfos) == 0 {
+		// The scatter fell back to round-robin (or we're on a mixed-version
+		// cluster that didn't return RangeInfos); there's no range descriptor
+		// to relocate against.
+		return dest, nil
+	}
+
+	newSpanKey, err := rewriteBackupSpanKey(codec, kr, key)
+	if err != nil {
+		return dest, err
+	}
+
+	zc, err := z.zoneConfigForKey(newSpanKey)
+	if err != nil {
+		log.Warningf(ctx, "could not resolve zone config for %s, skipping lease relocation: %+v", newSpanKey, err)
+		return dest, nil
+	}
+	if zc == nil || len(zc.LeasePreferences) == 0 {
+		return dest, nil
+	}
+
+	preferred, ok := z.relocationTargets(res.RangeInfos, zc)
+	if !ok {
+		return dest, nil
+	}
+
+	log.VEventf(ctx, 1, "relocating range at %s to satisfy lease preferences %v", newSpanKey, zc.LeasePreferences)
+	if err := db.AdminRelocateRange(
+		ctx, newSpanKey, preferred, nil /* nonVoterTargets */, false, /* transferLeaseToFirstVoter */
+	); err != nil {
+		log.Warningf(ctx, "failed to relocate range at %s: %+v", newSpanKey, err)
+		return dest, nil
+	}
+	if err := db.AdminTransferLease(ctx, newSpanKey, preferred[0].StoreID); err != nil {
+		log.Warningf(ctx, "failed to transfer lease for range at %s: %+v", newSpanKey, err)
+		return dest, nil
+	}
+
+	return dest, nil
+}
+
+// relocationTargets picks replica targets that satisfy zc's lease
+// preferences, ordering the preferred leaseholder first, followed by the
+// scattered range's other replicas unchanged. It returns ok=false if none of
+// the range's current replicas sit on a node matching any lease preference,
+// or if the replicas' localities aren't known (e.g. nodeLocalities is nil,
+// as it is for a tenant that can't see gossip).
+func (z *zoneAwareSplitAndScatterer) relocationTargets(
+	rangeInfos []roachpb.RangeInfo, zc *zonepb.ZoneConfig,
+) ([]roachpb.ReplicationTarget, bool) {
+	if z.nodeLocalities == nil {
+		return nil, false
+	}
+	localities := z.nodeLocalities()
+	if len(localities) == 0 {
+		return nil, false
+	}
+	replicas := rangeInfos[0].Desc.Replicas().Descriptors()
+
+	for _, pref := range zc.LeasePreferences {
+		for _, repl := range replicas {
+			loc, ok := localities[repl.NodeID]
+			if !ok || !localityMatchesConstraints(loc, pref.Constraints) {
+				continue
+			}
+			targets := make([]roachpb.ReplicationTarget, 0, len(replicas))
+			targets = append(targets, roachpb.ReplicationTarget{NodeID: repl.NodeID, StoreID: repl.StoreID})
+			for _, other := range replicas {
+				if other.NodeID == repl.NodeID {
+					continue
+				}
+				targets = append(targets, roachpb.ReplicationTarget{NodeID: other.NodeID, StoreID: other.StoreID})
+			}
+			return targets, true
+		}
+	}
+	return nil, false
+}
+
+// localityMatchesConstraints reports whether loc satisfies every constraint
+// in cs: every REQUIRED (or unspecified-type, which zone configs also treat
+// as required) tier must be present in loc, and no PROHIBITED tier may be.
+func localityMatchesConstraints(loc roachpb.Locality, cs []zonepb.Constraint) bool {
+	for _, c := range cs {
+		hasTier := false
+		for _, tier := range loc.Tiers {
+			if tier.Key == c.Key && tier.Value == c.Value {
+				hasTier = true
+				break
+			}
+		}
+		if c.Type == zonepb.Constraint_PROHIBITED {
+			if hasTier {
+				return false
+			}
+			continue
+		}
+		if !hasTier {
+			return false
+		}
+	}
+	return true
+}
+
 const splitAndScatterProcessorName = "splitAndScatter"
 
 var splitAndScatterOutputTypes = []*types.T{
@@ -154,6 +970,9 @@ type splitAndScatterProcessor struct {
 	// A cache for routing datums, so only 1 is allocated per node.
 	routingDatumCache map[roachpb.NodeID]rowenc.EncDatum
 	scatterErr        error
+
+	metrics    scatterMetrics
+	checkpoint *scatterCheckpoint
 }
 
 var _ execinfra.Processor = &splitAndScatterProcessor{}
@@ -170,22 +989,51 @@ func newSplitAndScatterProcessor(
 	post *execinfrapb.PostProcessSpec,
 	output execinfra.RowReceiver,
 ) (execinfra.Processor, error) {
-	numEntries := 0
-	for _, chunk := range spec.Chunks {
-		numEntries += len(chunk.Entries)
-	}
+	metrics := getScatterMetrics(flowCtx)
+	g := flowCtx.Cfg.Gossip
+	dbScatterer := makeDBSplitAndScatterer(
+		&flowCtx.Cfg.Settings.SV, metrics, func() []roachpb.NodeID {
+			gossiper, err := g.OptionalErr(47970 /* issue */)
+			if err != nil {
+				return nil
+			}
+			return liveNodeIDsFromGossip(gossiper)
+		})
 
-	var scatterer splitAndScatterer = dbSplitAndScatterer{}
+	var scatterer splitAndScatterer = dbScatterer
+	switch resolveScatterStrategy(spec, &flowCtx.Cfg.Settings.SV) {
+	case scatterStrategyZoneAware:
+		scatterer = makeZoneAwareSplitAndScatterer(dbScatterer, func(key roachpb.Key) (*zonepb.ZoneConfig, error) {
+			cfg := flowCtx.Cfg.SystemConfig.GetSystemConfig()
+			if cfg == nil {
+				return nil, errors.New("system config not yet available")
+			}
+			return cfg.GetZoneConfigForKey(key)
+		}, func() map[roachpb.NodeID]roachpb.Locality {
+			gossiper, err := g.OptionalErr(47970 /* issue */)
+			if err != nil {
+				return nil
+			}
+			return nodeLocalitiesFromGossip(gossiper)
+		})
+	case scatterStrategyNoop:
+		scatterer = noopSplitAndScatterer{}
+	}
 	if !flowCtx.Cfg.Codec.ForSystemTenant() {
 		scatterer = noopSplitAndScatterer{}
 	}
+	// doneScatterCh only needs to be deep enough to keep the workers that feed
+	// it from blocking on a slow consumer; it doesn't need to hold every
+	// entry in the plan; sizing it off the worker pool's max concurrency
+	// avoids loading the full plan into memory just to count entries.
+	doneScatterChCap := int(scatterWorkersMax.Get(&flowCtx.Cfg.Settings.SV)) * 2
 	ssp := &splitAndScatterProcessor{
-		flowCtx:   flowCtx,
-		spec:      spec,
-		output:    output,
-		scatterer: scatterer,
-		// Large enough so that it never blocks.
-		doneScatterCh:     make(chan entryNode, numEntries),
+		flowCtx:           flowCtx,
+		spec:              spec,
+		output:            output,
+		scatterer:         scatterer,
+		metrics:           metrics,
+		doneScatterCh:     make(chan entryNode, doneScatterChCap),
 		routingDatumCache: make(map[roachpb.NodeID]rowenc.EncDatum),
 	}
 	if err := ssp.Init(ssp, post, splitAndScatterOutputTypes, flowCtx, processorID, output, nil, /* memMonitor */
@@ -287,40 +1135,90 @@ func (ssp *splitAndScatterProcessor) runSplitAndScatter(
 	if err != nil {
 		return err
 	}
+
+	checkpointStore := jobRegistryCheckpointStore{registry: flowCtx.Cfg.JobRegistry, jobID: spec.JobID}
+	completed, err := loadScatterCheckpoint(ctx, checkpointStore)
+	if err != nil {
+		// A checkpoint that can't be loaded shouldn't fail the whole restore;
+		// we just lose the ability to skip already-completed work this time.
+		log.Warningf(ctx, "failed to load split/scatter checkpoint, resuming from scratch: %+v", err)
+	}
+	ssp.checkpoint = newScatterCheckpoint(checkpointStore, completed)
+
 	g := ctxgroup.WithContext(ctx)
 
-	importSpanChunksCh := make(chan []execinfrapb.RestoreSpanEntry)
+	type indexedChunk struct {
+		idx     int
+		entries []execinfrapb.RestoreSpanEntry
+	}
+
+	importSpanChunksCh := make(chan indexedChunk)
 	g.GoCtx(func(ctx context.Context) error {
 		defer close(importSpanChunksCh)
-		for _, importSpanChunk := range spec.Chunks {
-			_, err := scatterer.splitAndScatterKey(ctx, flowCtx.Codec(), db, kr, importSpanChunk.Entries[0].Span.Key, true /* randomizeLeases */)
-			if err != nil {
-				return err
+		for chunkIdx, importSpanChunk := range spec.Chunks {
+			remaining := importSpanChunk.Entries[:0:0]
+			for _, entry := range importSpanChunk.Entries {
+				if !ssp.checkpoint.isDone(entry.Span.Key) {
+					remaining = append(remaining, entry)
+				}
+			}
+			if len(remaining) == 0 {
+				log.VEventf(ctx, 1, "skipping chunk %d, already checkpointed", chunkIdx)
+				continue
+			}
+
+			if !ssp.checkpoint.isDone(importSpanChunk.Entries[0].Span.Key) {
+				_, err := scatterer.splitAndScatterKey(ctx, flowCtx.Codec(), db, kr, importSpanChunk.Entries[0].Span.Key, chunkIdx, true /* randomizeLeases */)
+				if err != nil {
+					return err
+				}
 			}
 
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
-			case importSpanChunksCh <- importSpanChunk.Entries:
+			case importSpanChunksCh <- indexedChunk{idx: chunkIdx, entries: remaining}:
 			}
 		}
 		return nil
 	})
 
-	// TODO(pbardea): This tries to cover for a bad scatter by having 2 * the
-	// number of nodes in the cluster. Is it necessary?
-	splitScatterWorkers := 2
-	for worker := 0; worker < splitScatterWorkers; worker++ {
+	sv := &flowCtx.Cfg.Settings.SV
+	minWorkers := int32(scatterWorkersMin.Get(sv))
+	maxWorkers := int32(scatterWorkersMax.Get(sv))
+	initialWorkers := int32(scatterWorkersInitial.Get(sv))
+	if initialWorkers == 0 {
+		initialWorkers = liveNodeCountOrDefault(flowCtx, minWorkers)
+	}
+	pool := newAdaptiveWorkerPool(minWorkers, maxWorkers, initialWorkers, ssp.metrics)
+	slowThreshold := scatterSlowThreshold.Get(sv)
+
+	// Workers are spawned up to maxWorkers, but the adaptive pool's permits
+	// (acquired below) are what actually bound concurrency; idle workers
+	// simply block waiting for a permit or more work.
+	for worker := int32(0); worker < maxWorkers; worker++ {
 		g.GoCtx(func(ctx context.Context) error {
 			for importSpanChunk := range importSpanChunksCh {
 				log.Infof(ctx, "processing a chunk")
-				for _, importSpan := range importSpanChunk {
+				for _, importSpan := range importSpanChunk.entries {
+					if err := pool.acquire(ctx); err != nil {
+						return err
+					}
+
 					log.Infof(ctx, "processing a span [%s,%s)", importSpan.Span.Key, importSpan.Span.EndKey)
-					destination, err := scatterer.splitAndScatterKey(ctx, flowCtx.Codec(), db, kr, importSpan.Span.Key, false /* randomizeLeases */)
+					start := timeutil.Now()
+					destination, err := scatterer.splitAndScatterKey(ctx, flowCtx.Codec(), db, kr, importSpan.Span.Key, importSpanChunk.idx, false /* randomizeLeases */)
+					degraded := err != nil || timeutil.Since(start) > slowThreshold
+					pool.release(degraded)
 					if err != nil {
 						return err
 					}
 
+					ssp.checkpoint.markDone(importSpan.Span.Key)
+					if err := ssp.checkpoint.maybeFlush(ctx, sv); err != nil {
+						log.Warningf(ctx, "failed to checkpoint split/scatter progress: %+v", err)
+					}
+
 					scatteredEntry := entryNode{
 						entry: importSpan,
 						node:  destination,
@@ -337,7 +1235,76 @@ func (ssp *splitAndScatterProcessor) runSplitAndScatter(
 		})
 	}
 
-	return g.Wait()
+	err = g.Wait()
+	if flushErr := ssp.checkpoint.flush(ctx); flushErr != nil {
+		log.Warningf(ctx, "failed to persist final split/scatter checkpoint: %+v", flushErr)
+	}
+	return err
+}
+
+// liveNodeCountOrDefault returns the number of live nodes known via gossip,
+// for use as the adaptive worker pool's initial concurrency. If gossip isn't
+// available or reports no nodes, it falls back to def.
+func liveNodeCountOrDefault(flowCtx *execinfra.FlowCtx, def int32) int32 {
+	gossiper, err := flowCtx.Cfg.Gossip.OptionalErr(47970 /* issue */)
+	if err != nil {
+		return def
+	}
+	if n := len(liveNodeIDsFromGossip(gossiper)); n > 0 {
+		return int32(n)
+	}
+	return def
+}
+
+// liveNodeIDsFromGossip returns the IDs of the nodes known to be live via
+// gossip, for use as a round-robin fallback destination when a scatter
+// can't be completed. It returns nil if gossip isn't available (e.g. when
+// running in a tenant) or no live nodes could be determined.
+func liveNodeIDsFromGossip(g *gossip.Gossip) []roachpb.NodeID {
+	var nodeIDs []roachpb.NodeID
+	for _, desc := range nodeDescriptorsFromGossip(g) {
+		nodeIDs = append(nodeIDs, desc.NodeID)
+	}
+	return nodeIDs
+}
+
+// nodeLocalitiesFromGossip returns the localities of the nodes known to be
+// live via gossip, keyed by node ID. It's used by the zone-aware scatterer to
+// figure out which of a range's replicas (if any) satisfy a zone config's
+// lease preferences.
+func nodeLocalitiesFromGossip(g *gossip.Gossip) map[roachpb.NodeID]roachpb.Locality {
+	descs := nodeDescriptorsFromGossip(g)
+	if len(descs) == 0 {
+		return nil
+	}
+	localities := make(map[roachpb.NodeID]roachpb.Locality, len(descs))
+	for _, desc := range descs {
+		localities[desc.NodeID] = desc.Locality
+	}
+	return localities
+}
+
+// nodeDescriptorsFromGossip returns the descriptors of the nodes known to be
+// live via gossip. It returns nil if gossip isn't available (e.g. when
+// running in a tenant) or no live nodes could be determined.
+func nodeDescriptorsFromGossip(g *gossip.Gossip) []roachpb.NodeDescriptor {
+	if g == nil {
+		return nil
+	}
+	var descs []roachpb.NodeDescriptor
+	_ = g.IterateInfos(gossip.KeyNodeIDPrefix, func(_ string, info gossip.Info) error {
+		raw, ok := info.Value.GetBytes()
+		if !ok {
+			return nil //nolint:nilerr
+		}
+		var nodeDesc roachpb.NodeDescriptor
+		if err := protoutil.Unmarshal(raw, &nodeDesc); err != nil {
+			return nil //nolint:nilerr
+		}
+		descs = append(descs, nodeDesc)
+		return nil
+	})
+	return descs
 }
 
 func routingDatumsForNode(nodeID roachpb.NodeID) (rowenc.EncDatum, rowenc.EncDatum) {
@@ -0,0 +1,276 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Licensed as a CockroachDB Enterprise file under the Cockroach Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+//     https://github.com/cockroachdb/cockroach/blob/master/licenses/CCL.txt
+
+package backupccl
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
+	"github.com/cockroachdb/cockroach/pkg/jobs"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/sql/execinfra"
+	"github.com/cockroachdb/cockroach/pkg/sql/execinfrapb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDBSplitAndScattererRoundRobinFallback verifies that roundRobinFallback
+// cycles through the known live nodes in order, rather than funneling every
+// failed scatter onto the same node (or onto node 0).
+func TestDBSplitAndScattererRoundRobinFallback(t *testing.T) {
+	nodes := []roachpb.NodeID{3, 1, 2}
+	s := makeDBSplitAndScatterer(nil /* settings */, scatterMetrics{}, func() []roachpb.NodeID {
+		return nodes
+	})
+
+	var got []roachpb.NodeID
+	for i := 0; i < len(nodes)*2; i++ {
+		dest, ok := s.roundRobinFallback()
+		require.True(t, ok)
+		got = append(got, dest)
+	}
+	require.Equal(t, []roachpb.NodeID{3, 1, 2, 3, 1, 2}, got)
+}
+
+// TestDBSplitAndScattererRoundRobinFallbackNoNodes verifies that
+// roundRobinFallback reports ok=false (rather than defaulting to node 0)
+// when no fallback nodes are known, so callers can fall back to the
+// destination-zero accounting path instead of silently hot-spotting node 0.
+func TestDBSplitAndScattererRoundRobinFallbackNoNodes(t *testing.T) {
+	s := makeDBSplitAndScatterer(nil /* settings */, scatterMetrics{}, func() []roachpb.NodeID {
+		return nil
+	})
+	_, ok := s.roundRobinFallback()
+	require.False(t, ok)
+
+	s = makeDBSplitAndScatterer(nil /* settings */, scatterMetrics{}, nil /* fallbackNodes */)
+	_, ok = s.roundRobinFallback()
+	require.False(t, ok)
+}
+
+// TestResolveScatterStrategy verifies that an explicit per-job strategy on
+// the spec takes precedence over the bulkio.restore.scatter.strategy cluster
+// setting, and that the cluster setting is used as a fallback when the spec
+// doesn't request a specific strategy.
+func TestResolveScatterStrategy(t *testing.T) {
+	sv := &settings.Values{}
+	sv.Init(settings.TestOpaque)
+	scatterStrategySetting.Override(sv, scatterStrategyZoneAware)
+
+	require.Equal(t, scatterStrategyZoneAware, resolveScatterStrategy(execinfrapb.SplitAndScatterSpec{}, sv))
+	require.Equal(t, scatterStrategyNoop, resolveScatterStrategy(
+		execinfrapb.SplitAndScatterSpec{ScatterStrategy: scatterStrategyNoop}, sv))
+}
+
+// TestLocalityMatchesConstraints exercises the required/prohibited tier
+// matching used to decide whether a replica satisfies a zone config's lease
+// preference.
+func TestLocalityMatchesConstraints(t *testing.T) {
+	loc := roachpb.Locality{Tiers: []roachpb.Tier{{Key: "region", Value: "us-east"}, {Key: "az", Value: "b"}}}
+
+	require.True(t, localityMatchesConstraints(loc, []zonepb.Constraint{
+		{Type: zonepb.Constraint_REQUIRED, Key: "region", Value: "us-east"},
+	}))
+	require.False(t, localityMatchesConstraints(loc, []zonepb.Constraint{
+		{Type: zonepb.Constraint_REQUIRED, Key: "region", Value: "us-west"},
+	}))
+	require.False(t, localityMatchesConstraints(loc, []zonepb.Constraint{
+		{Type: zonepb.Constraint_PROHIBITED, Key: "region", Value: "us-east"},
+	}))
+	require.True(t, localityMatchesConstraints(loc, []zonepb.Constraint{
+		{Type: zonepb.Constraint_PROHIBITED, Key: "region", Value: "us-west"},
+	}))
+}
+
+// TestZoneAwareRelocationTargets verifies that relocationTargets picks the
+// replica whose locality matches the zone config's lease preference and
+// orders it first, and that it reports ok=false when no replica matches (so
+// the caller leaves placement to the allocator instead of guessing).
+func TestZoneAwareRelocationTargets(t *testing.T) {
+	desc := roachpb.RangeDescriptor{
+		InternalReplicas: []roachpb.ReplicaDescriptor{
+			{NodeID: 1, StoreID: 1},
+			{NodeID: 2, StoreID: 2},
+			{NodeID: 3, StoreID: 3},
+		},
+	}
+	rangeInfos := []roachpb.RangeInfo{{Desc: desc}}
+	zc := &zonepb.ZoneConfig{
+		LeasePreferences: []zonepb.LeasePreference{
+			{Constraints: []zonepb.Constraint{{Type: zonepb.Constraint_REQUIRED, Key: "region", Value: "us-east"}}},
+		},
+	}
+
+	z := &zoneAwareSplitAndScatterer{
+		nodeLocalities: func() map[roachpb.NodeID]roachpb.Locality {
+			return map[roachpb.NodeID]roachpb.Locality{
+				2: {Tiers: []roachpb.Tier{{Key: "region", Value: "us-east"}}},
+			}
+		},
+	}
+	targets, ok := z.relocationTargets(rangeInfos, zc)
+	require.True(t, ok)
+	require.Equal(t, roachpb.NodeID(2), targets[0].NodeID)
+	require.ElementsMatch(t, []roachpb.NodeID{1, 2, 3}, []roachpb.NodeID{targets[0].NodeID, targets[1].NodeID, targets[2].NodeID})
+
+	z.nodeLocalities = func() map[roachpb.NodeID]roachpb.Locality {
+		return map[roachpb.NodeID]roachpb.Locality{2: {Tiers: []roachpb.Tier{{Key: "region", Value: "us-west"}}}}
+	}
+	_, ok = z.relocationTargets(rangeInfos, zc)
+	require.False(t, ok)
+}
+
+// fakeCheckpointStore is an in-memory checkpointStore used to simulate a
+// RESTORE job's progress record across multiple "attempts" in a test,
+// without requiring a real jobs.Registry.
+type fakeCheckpointStore struct {
+	mu        sync.Mutex
+	completed []string
+}
+
+// Load implements checkpointStore.
+func (s *fakeCheckpointStore) Load(_ context.Context) (map[string]struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[string]struct{}, len(s.completed))
+	for _, k := range s.completed {
+		m[k] = struct{}{}
+	}
+	return m, nil
+}
+
+// Save implements checkpointStore.
+func (s *fakeCheckpointStore) Save(_ context.Context, completed []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completed = append([]string(nil), completed...)
+	return nil
+}
+
+// TestScatterCheckpointResumeIsIdempotent simulates a split/scatter
+// processor that's killed partway through a RESTORE (persisting only the
+// entries it finished before dying) and then resumed against the same
+// checkpoint store. It verifies that every entry is scattered exactly once
+// across both attempts, which is the property the checkpoint exists to
+// guarantee.
+func TestScatterCheckpointResumeIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeCheckpointStore{}
+	keys := []roachpb.Key{
+		roachpb.Key("a"), roachpb.Key("b"), roachpb.Key("c"), roachpb.Key("d"), roachpb.Key("e"),
+	}
+
+	scatterCalls := make(map[string]int)
+	scatter := func(key roachpb.Key) {
+		scatterCalls[string(key)]++
+	}
+
+	processEntries := func(entries []roachpb.Key) {
+		initial, err := loadScatterCheckpoint(ctx, store)
+		require.NoError(t, err)
+		checkpoint := newScatterCheckpoint(store, initial)
+		for _, key := range entries {
+			if checkpoint.isDone(key) {
+				continue
+			}
+			scatter(key)
+			checkpoint.markDone(key)
+		}
+		require.NoError(t, checkpoint.flush(ctx))
+	}
+
+	// First attempt dies after handling only the first three entries.
+	processEntries(keys[:3])
+
+	// The "resumed" attempt sees the full entry list again (as
+	// runSplitAndScatter would after a processor restart), but should skip
+	// the three already-checkpointed entries.
+	processEntries(keys)
+
+	for _, key := range keys {
+		require.Equalf(t, 1, scatterCalls[string(key)], "key %q should have been scattered exactly once", key)
+	}
+}
+
+// TestAdaptiveWorkerPoolAIMD exercises the adaptive worker pool's AIMD
+// behavior: a degraded release halves the concurrency limit (down to min),
+// and a streak of healthy releases grows it back by one.
+func TestAdaptiveWorkerPoolAIMD(t *testing.T) {
+	ctx := context.Background()
+	metrics := makeScatterMetrics()
+	p := newAdaptiveWorkerPool(1 /* min */, 8 /* max */, 8 /* initial */, metrics)
+	require.EqualValues(t, 8, atomic.LoadInt32(&p.cur))
+
+	require.NoError(t, p.acquire(ctx))
+	p.release(true /* degraded */)
+	require.EqualValues(t, 4, atomic.LoadInt32(&p.cur))
+
+	for i := 0; i < scatterSuccessStreakForIncrease; i++ {
+		require.NoError(t, p.acquire(ctx))
+		p.release(false /* degraded */)
+	}
+	require.EqualValues(t, 5, atomic.LoadInt32(&p.cur))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.acquire(ctx))
+		p.release(true /* degraded */)
+	}
+	require.EqualValues(t, 1, atomic.LoadInt32(&p.cur))
+}
+
+// TestGetScatterMetricsPerNode verifies that getScatterMetrics registers and
+// caches a distinct set of counters per node (i.e. per *jobs.Registry), so
+// that a process hosting more than one logical node/server — as every
+// TestCluster and most TestServer-based tests do — gets scatter metrics
+// wired up for every node, not just whichever node happens to call
+// getScatterMetrics first.
+func TestGetScatterMetricsPerNode(t *testing.T) {
+	defer func() {
+		scatterMetricsMu.Lock()
+		scatterMetricsMu.byRegistry = nil
+		scatterMetricsMu.Unlock()
+	}()
+
+	regA := &jobs.Registry{}
+	regB := &jobs.Registry{}
+	flowA := &execinfra.FlowCtx{Cfg: &execinfra.ServerConfig{JobRegistry: regA}}
+	flowB := &execinfra.FlowCtx{Cfg: &execinfra.ServerConfig{JobRegistry: regB}}
+
+	mA1 := getScatterMetrics(flowA)
+	mB := getScatterMetrics(flowB)
+	mA2 := getScatterMetrics(flowA)
+
+	require.Same(t, mA1.Attempts, mA2.Attempts, "repeated calls for the same node should share counters")
+	require.NotSame(t, mA1.Attempts, mB.Attempts, "different nodes must not share counters")
+}
+
+// TestAdaptiveWorkerPoolFloorsMinAboveZero verifies that a misconfigured (or
+// defaulted) min of 0 is floored to 1, so a burst of degraded releases can
+// never CAS the pool's concurrency down to 0 permits, which would otherwise
+// leave acquire() blocked forever with nothing left to hand out.
+func TestAdaptiveWorkerPoolFloorsMinAboveZero(t *testing.T) {
+	ctx := context.Background()
+	metrics := makeScatterMetrics()
+	p := newAdaptiveWorkerPool(0 /* min */, 4 /* max */, 4 /* initial */, metrics)
+	require.EqualValues(t, 1, p.min)
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, p.acquire(ctx))
+		p.release(true /* degraded */)
+	}
+	require.EqualValues(t, 1, atomic.LoadInt32(&p.cur))
+
+	// The pool must still be able to hand out a permit; a floor that let cur
+	// reach 0 would hang here forever.
+	require.NoError(t, p.acquire(ctx))
+	p.release(false /* degraded */)
+}